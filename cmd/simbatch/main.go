@@ -0,0 +1,206 @@
+// Command simbatch runs batch simulations of BondMachine designs using a CSV
+// input file and producing a CSV output file. It is a thin wrapper over
+// github.com/BondMachineHQ/simbatch/pkg/simbatch.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BondMachineHQ/simbatch/pkg/simbatch"
+)
+
+func usage() {
+	fmt.Println("SimBatch: A batch simulator for BondMachine designs")
+	fmt.Println("")
+	fmt.Println("SimBatch allows you to run batch simulations of BondMachine designs using a CSV input file and producing a CSV output file.")
+	fmt.Println("It expects the BondMachine design to be already compiled in the working directory called `bondmachine.json`.")
+	fmt.Println("")
+	fmt.Println("Usage: simbatch [options]")
+	fmt.Println("Options:")
+	fmt.Println("  -w, --working-dir DIR        set the working directory (default: working_dir)")
+	fmt.Println("  -i, --input-file FILE        set the input CSV file (default: simbatch_input.csv)")
+	fmt.Println("  -o, --output-file FILE       set the output CSV file (default: working_dir/simbatch_output.csv)")
+	fmt.Println("  -s, --simulation-steps N     number of simulation steps (default: 200)")
+	fmt.Println("  -m, --ml                     enable ML output formatting (probabilities + classification)")
+	fmt.Println("  -b, --benchcore              enable benchcore mode")
+	fmt.Println("  -H, --header                 include header row in output CSV")
+	fmt.Println("  -P, --prefix                 include data type prefix in output CSV")
+	fmt.Println("  -d, --data-type TYPE         data type for outputs (e.g. float32) (default: float32)")
+	fmt.Println("  -l, --linear-data-range RANGE    pass a linear data range option to bondmachine/bmnumbers")
+	fmt.Println("  -v, --stop-on-valid-of N     accepted but ignored: the simulation always stops on valid of the last output")
+	fmt.Println("  -h, --help                   show this help message and exit")
+	fmt.Println("  -y, --delays-file FILE       set the delays file")
+	fmt.Println("  -j, --jobs N                 number of rows to process concurrently (default: 1)")
+	fmt.Println("  -abort-on-error              stop the whole batch on the first row error")
+	fmt.Println("  -B, --bench RUNS             re-run the simulation RUNS times per row and report timing stats")
+	fmt.Println("  -J, --json-report FILE       write the benchmark report (see -B) as JSON to FILE")
+	fmt.Println("  -t, --timeout DURATION       kill a single simulation run after DURATION (e.g. 5s, 1m)")
+	fmt.Println("  -g, --ground-truth COL       0-based input column holding the expected class (ML mode)")
+	fmt.Println("  -M, --metrics-file FILE      write accuracy/precision/recall/F1/confusion-matrix JSON to FILE")
+	fmt.Println("  -fast-simbox                 prepare each row's simbox file in-process instead of shelling out to simbox per directive (unverified against real simbox output, opt-in)")
+	fmt.Println("  -F, --format {csv,tsv,jsonl} output format (default: csv)")
+	fmt.Println("  -S, --schema FILE            JSON schema file overriding the output data type per column")
+	fmt.Println("")
+	fmt.Println("Example:")
+	fmt.Println("  simbatch -w working_dir -i input.csv -o out.csv -s 200")
+}
+
+func main() {
+	var (
+		workingDir      string
+		inputFile       string
+		outputFile      string
+		simulationSteps = "200"
+		isML            bool
+		benchCore       bool
+		stopOnValidOf   = -1
+		dataType        = "float32"
+		linearDataRange string
+		includePrefix   bool
+		delaysFile      string
+		jobs            int
+		abortOnError    bool
+		benchRuns       int
+		jsonReportFile  string
+		timeoutArg      string
+		groundTruthCol  = -1
+		metricsFile     string
+		header          bool
+		format          = "csv"
+		schemaFile      string
+	)
+
+	flag.StringVar(&workingDir, "w", "", "working directory")
+	flag.StringVar(&workingDir, "working-dir", "", "working directory")
+	flag.StringVar(&inputFile, "i", "", "input CSV file")
+	flag.StringVar(&inputFile, "input-file", "", "input CSV file")
+	flag.StringVar(&outputFile, "o", "", "output CSV file")
+	flag.StringVar(&outputFile, "output-file", "", "output CSV file")
+	flag.StringVar(&simulationSteps, "s", "200", "simulation steps")
+	flag.StringVar(&simulationSteps, "simulation-steps", "200", "simulation steps")
+	flag.BoolVar(&isML, "m", false, "enable ML output")
+	flag.BoolVar(&isML, "ml", false, "enable ML output")
+	flag.BoolVar(&benchCore, "b", false, "enable benchcore")
+	flag.BoolVar(&benchCore, "benchcore", false, "enable benchcore")
+	flag.BoolVar(&header, "H", false, "include header")
+	flag.BoolVar(&header, "header", false, "include header")
+	flag.BoolVar(&includePrefix, "P", false, "include prefix")
+	flag.BoolVar(&includePrefix, "prefix", false, "include prefix")
+	flag.StringVar(&dataType, "d", "float32", "data type")
+	flag.StringVar(&dataType, "data-type", "float32", "data type")
+	flag.StringVar(&linearDataRange, "l", "", "linear data range")
+	flag.StringVar(&linearDataRange, "linear-data-range", "", "linear data range")
+	flag.IntVar(&stopOnValidOf, "v", -1, "stop on valid of")
+	flag.IntVar(&stopOnValidOf, "stop-on-valid-of", -1, "stop on valid of")
+	flag.StringVar(&delaysFile, "y", "", "delays file")
+	flag.StringVar(&delaysFile, "delays-file", "", "delays file")
+	flag.IntVar(&jobs, "j", 1, "number of concurrent worker goroutines")
+	flag.IntVar(&jobs, "jobs", 1, "number of concurrent worker goroutines")
+	flag.BoolVar(&abortOnError, "abort-on-error", false, "stop the batch on the first row error")
+	flag.IntVar(&benchRuns, "B", 0, "re-run each row's simulation this many times and report timing stats")
+	flag.IntVar(&benchRuns, "bench", 0, "re-run each row's simulation this many times and report timing stats")
+	flag.StringVar(&jsonReportFile, "J", "", "write the benchmark report as JSON to this file")
+	flag.StringVar(&jsonReportFile, "json-report", "", "write the benchmark report as JSON to this file")
+	flag.StringVar(&timeoutArg, "t", "", "kill a single simulation run after this duration")
+	flag.StringVar(&timeoutArg, "timeout", "", "kill a single simulation run after this duration")
+	flag.IntVar(&groundTruthCol, "g", -1, "0-based input column holding the expected class")
+	flag.IntVar(&groundTruthCol, "ground-truth", -1, "0-based input column holding the expected class")
+	flag.StringVar(&metricsFile, "M", "", "write the ML evaluation metrics as JSON to this file")
+	flag.StringVar(&metricsFile, "metrics-file", "", "write the ML evaluation metrics as JSON to this file")
+	fastSimbox := flag.Bool("fast-simbox", false, "prepare each row's simbox file in-process instead of shelling out to simbox per directive (unverified against real simbox output, opt-in)")
+	flag.StringVar(&format, "F", "csv", "output format: csv, tsv, or jsonl")
+	flag.StringVar(&format, "format", "csv", "output format: csv, tsv, or jsonl")
+	flag.StringVar(&schemaFile, "S", "", "JSON schema file overriding the output data type per column")
+	flag.StringVar(&schemaFile, "schema", "", "JSON schema file overriding the output data type per column")
+	helpFlag := flag.Bool("h", false, "show help")
+	helpFlagLong := flag.Bool("help", false, "show help")
+
+	flag.Parse()
+
+	if *helpFlag || *helpFlagLong {
+		usage()
+		os.Exit(0)
+	}
+
+	if workingDir == "" {
+		workingDir = "working_dir"
+	}
+	if inputFile == "" {
+		inputFile = "simbatch_input.csv"
+	}
+	if outputFile == "" {
+		outputFile = workingDir + "/simbatch_output.csv"
+	}
+	switch format {
+	case simbatch.FormatCSV, simbatch.FormatTSV, simbatch.FormatJSONL:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unsupported -format %q (want csv, tsv, or jsonl)\n", format)
+		os.Exit(1)
+	}
+
+	cfg := simbatch.Config{
+		WorkingDir:      workingDir,
+		SimulationSteps: simulationSteps,
+		IsML:            isML,
+		BenchCore:       benchCore,
+		StopOnValidOf:   stopOnValidOf,
+		DataType:        dataType,
+		Header:          header,
+		OmitPrefix:      !includePrefix,
+		DelaysFile:      delaysFile,
+		Jobs:            jobs,
+		AbortOnError:    abortOnError,
+		BenchRuns:       benchRuns,
+		JSONReportFile:  jsonReportFile,
+		MetricsFile:     metricsFile,
+		FastSimbox:      *fastSimbox,
+		Format:          format,
+		SchemaFile:      schemaFile,
+	}
+	if groundTruthCol >= 0 {
+		cfg.GroundTruthCol = &groundTruthCol
+	}
+	if linearDataRange != "" {
+		cfg.LinearDataRange = "-linear-data-range " + linearDataRange
+	}
+	if delaysFile != "" {
+		cfg.DelayString = "-sim-delays-file " + delaysFile
+	}
+	if timeoutArg != "" {
+		d, err := time.ParseDuration(timeoutArg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing -timeout: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.Timeout = d
+	}
+
+	runner, err := simbatch.New(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	inputFileHandle, err := os.Open(inputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening input file: %v\n", err)
+		os.Exit(1)
+	}
+	defer inputFileHandle.Close()
+
+	outputFileHandle, err := os.Create(outputFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+		os.Exit(1)
+	}
+	defer outputFileHandle.Close()
+
+	if err := runner.RunCSV(context.Background(), inputFileHandle, outputFileHandle); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+}
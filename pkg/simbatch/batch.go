@@ -0,0 +1,235 @@
+package simbatch
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// inputRow is one parsed CSV row: the comma-joined input values simbox needs,
+// plus the ground-truth class (only meaningful when GroundTruthCol != nil).
+type inputRow struct {
+	line        string
+	groundTruth int
+}
+
+// mlEvalResult is one row's classification outcome paired with its
+// ground-truth label, collected for the GroundTruthCol accuracy/AUC/
+// confusion matrix report once the whole batch has finished.
+type mlEvalResult struct {
+	predicted   int
+	groundTruth int
+	posProb     float64
+}
+
+// orderedResult is one worker's output, tagged with its original row index
+// so the writer goroutine can flush rows in input order even though workers
+// finish out of order.
+type orderedResult struct {
+	index  int
+	line   string
+	report *RowReport
+	ml     *mlPrediction
+}
+
+// resultHeap is a min-heap of orderedResult keyed by index, used by the
+// writer goroutine to buffer out-of-order results until the next expected
+// index becomes available.
+type resultHeap []orderedResult
+
+func (h resultHeap) Len() int            { return len(h) }
+func (h resultHeap) Less(i, j int) bool  { return h[i].index < h[j].index }
+func (h resultHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x interface{}) { *h = append(*h, x.(orderedResult)) }
+func (h *resultHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// runRowsParallel dispatches rows to r.Config.Jobs worker goroutines, each
+// using its own Runner (via WithSimboxFile) so the simbox file is never
+// shared across goroutines. Results are written to w in original input-row
+// order via a min-heap keyed by row index.
+func runRowsParallel(ctx context.Context, r *Runner, rows []inputRow, w *bufio.Writer) ([]RowReport, []mlEvalResult, error) {
+	results := make(chan orderedResult, len(rows))
+	var wg sync.WaitGroup
+	var abort int32
+
+	rowCh := make(chan int)
+	go func() {
+		defer close(rowCh)
+		for i := range rows {
+			if r.Config.AbortOnError && atomic.LoadInt32(&abort) != 0 {
+				return
+			}
+			rowCh <- i
+		}
+	}()
+
+	workers := r.Config.Jobs
+	if workers < 1 {
+		workers = 1
+	}
+	for wk := 0; wk < workers; wk++ {
+		wg.Add(1)
+		worker := r.WithSimboxFile(fmt.Sprintf("%s/simboxtemp_%d.json", r.Config.WorkingDir, wk))
+		go func() {
+			defer wg.Done()
+			for i := range rowCh {
+				var outline string
+				var report *RowReport
+				var ml *mlPrediction
+				var err error
+				if r.Config.BenchRuns > 0 {
+					outline, report, ml, err = benchRowCSV(ctx, worker, rows[i].line)
+				} else {
+					outline, ml, err = processRowCSV(ctx, worker, rows[i].line)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error processing row %d: %v\n", i, err)
+					errLine := formatErrorLine(r.Config.Format, err)
+					if r.Config.AbortOnError {
+						atomic.StoreInt32(&abort, 1)
+						results <- orderedResult{index: i, line: errLine}
+						return
+					}
+					results <- orderedResult{index: i, line: errLine}
+					continue
+				}
+				results <- orderedResult{index: i, line: outline, report: report, ml: ml}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	reports := make([]RowReport, len(rows))
+	haveReports := false
+	var mlResults []mlEvalResult
+	pending := &resultHeap{}
+	heap.Init(pending)
+	next := 0
+	for res := range results {
+		heap.Push(pending, res)
+		for pending.Len() > 0 && (*pending)[0].index == next {
+			item := heap.Pop(pending).(orderedResult)
+			fmt.Fprintln(w, item.line)
+			if item.report != nil {
+				reports[item.index] = *item.report
+				haveReports = true
+			}
+			if item.ml != nil && r.Config.GroundTruthCol != nil {
+				posProb := 0.0
+				if len(item.ml.probs) > 1 {
+					posProb = float64(item.ml.probs[1])
+				}
+				mlResults = append(mlResults, mlEvalResult{
+					predicted:   item.ml.predicted,
+					groundTruth: rows[item.index].groundTruth,
+					posProb:     posProb,
+				})
+			}
+			next++
+		}
+	}
+
+	if r.Config.AbortOnError && atomic.LoadInt32(&abort) != 0 {
+		return nil, nil, fmt.Errorf("aborted on error")
+	}
+	if !haveReports {
+		reports = nil
+	}
+	return reports, mlResults, nil
+}
+
+// benchRowCSV re-runs a row's simulation r.Config.BenchRuns times against the
+// same prepared simbox file, recording per-run latency_cycles and wall-clock
+// duration. A run that exceeds r.Config.Timeout is counted as a timeout
+// rather than failing the row outright; if every run times out, the row fails
+// with an error and its report entry is left at its zero value. It also
+// returns the row's ML
+// prediction (same as processRowCSV) so -B can be combined with -g
+// ground-truth evaluation.
+func benchRowCSV(ctx context.Context, r *Runner, line string) (string, *RowReport, *mlPrediction, error) {
+	inputsValues := strings.Split(line, ",")
+	if len(inputsValues) != len(r.Inputs) {
+		return "", nil, nil, fmt.Errorf("the input file has an invalid number of columns")
+	}
+
+	fmt.Printf("Benchmarking %d run(s) with inputs: %s\n", r.Config.BenchRuns, line)
+
+	if err := prepareSimbox(r, inputsValues); err != nil {
+		return "", nil, nil, err
+	}
+
+	var latencies, wallNs []float64
+	var lastOutline string
+	timeouts := 0
+
+	for i := 0; i < r.Config.BenchRuns; i++ {
+		runCtx := ctx
+		cancel := func() {}
+		if r.Config.Timeout > 0 {
+			runCtx, cancel = context.WithTimeout(ctx, r.Config.Timeout)
+		}
+
+		start := time.Now()
+		outline, err := runSimOnce(runCtx, r)
+		elapsed := time.Since(start)
+		cancel()
+
+		if err != nil {
+			if err == context.DeadlineExceeded {
+				timeouts++
+				continue
+			}
+			return "", nil, nil, err
+		}
+
+		lastOutline = outline
+		wallNs = append(wallNs, float64(elapsed.Nanoseconds()))
+		if r.Config.BenchCore {
+			_, latencyStr, _, _ := formatCSVLine(r.Config, outline)
+			if v, perr := strconv.ParseFloat(latencyStr, 64); perr == nil {
+				latencies = append(latencies, v)
+			}
+		}
+	}
+
+	if lastOutline == "" {
+		return "", nil, nil, fmt.Errorf("all %d runs timed out", r.Config.BenchRuns)
+	}
+
+	outVals := strings.Fields(lastOutline)
+	if r.Config.BenchCore && len(outVals) > 0 {
+		outVals = outVals[:len(outVals)-1]
+	}
+
+	report := &RowReport{
+		Inputs:        inputsValues,
+		Outputs:       outVals,
+		Runs:          r.Config.BenchRuns,
+		Timeouts:      timeouts,
+		LatencyCycles: computeStats(latencies),
+		WallNs:        computeStats(wallNs),
+	}
+
+	line, ml, err := formatRow(r, inputsValues, lastOutline)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return line, report, ml, nil
+}
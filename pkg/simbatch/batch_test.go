@@ -0,0 +1,65 @@
+package simbatch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestRunRowsParallelPreservesOrder is a regression test for runRowsParallel's
+// ordering guarantee: workers finish rows out of order, but the heap-backed
+// writer must still flush them in original input-row order. The fake
+// bondmachine below sleeps longer for earlier rows, so a writer that simply
+// forwarded results as workers finished them would reorder the output.
+func TestRunRowsParallelPreservesOrder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test fakes bondmachine with a sh script")
+	}
+
+	binDir := t.TempDir()
+	fakeBondmachine := filepath.Join(binDir, "bondmachine")
+	script := `#!/bin/sh
+prev=""
+file=""
+for a in "$@"; do
+  if [ "$prev" = "-simbox-file" ]; then
+    file="$a"
+  fi
+  prev="$a"
+done
+val=$(grep -o 'absolute:0:set:i0:[0-9]*' "$file" | head -1 | sed 's/.*://')
+case "$val" in
+  10) sleep 0.05 ;;
+  20) sleep 0.03 ;;
+  30) sleep 0.01 ;;
+esac
+echo "$val"
+`
+	if err := os.WriteFile(fakeBondmachine, []byte(script), 0755); err != nil {
+		t.Fatalf("writing fake bondmachine: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	r := &Runner{
+		Config:  Config{WorkingDir: t.TempDir(), Jobs: 3, FastSimbox: true},
+		Inputs:  map[string]string{"0": "i0"},
+		Outputs: map[string]string{"out0": "o0"},
+	}
+	rows := []inputRow{{line: "10"}, {line: "20"}, {line: "30"}}
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	if _, _, err := runRowsParallel(context.Background(), r, rows, w); err != nil {
+		t.Fatalf("runRowsParallel: %v", err)
+	}
+	w.Flush()
+
+	want := "10\n20\n30\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+package simbatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Output formats supported by Config.Format. The zero value behaves like
+// FormatCSV.
+const (
+	FormatCSV   = "csv"
+	FormatTSV   = "tsv"
+	FormatJSONL = "jsonl"
+)
+
+// formatRow turns a raw, prefix-stripped simulation outline into the output
+// line RunCSV writes, in whichever format Config.Format selects.
+func formatRow(r *Runner, inputsValues []string, outline string) (string, *mlPrediction, error) {
+	if r.Config.Format == FormatJSONL {
+		return formatJSONLLine(r, inputsValues, outline)
+	}
+
+	csvLine, _, probs, predicted := formatCSVLine(r.Config, outline)
+	var ml *mlPrediction
+	if r.Config.IsML {
+		ml = &mlPrediction{predicted: predicted, probs: probs}
+	}
+	return csvLine, ml, nil
+}
+
+// formatErrorLine formats a row-processing error as an output line in the
+// given Config.Format, so a row error doesn't break a jsonl stream's
+// line-by-line JSON parsing the way a bare "ERROR:<msg>" line would.
+func formatErrorLine(format string, err error) string {
+	if format != FormatJSONL {
+		return fmt.Sprintf("ERROR:%v", err)
+	}
+
+	data, _ := json.Marshal(struct {
+		Error string `json:"error"`
+	}{Error: err.Error()})
+	return string(data)
+}
+
+// jsonlRow is the document formatJSONLLine writes per row, one per line.
+type jsonlRow struct {
+	Inputs         map[string]string      `json:"inputs"`
+	Outputs        map[string]interface{} `json:"outputs"`
+	Classification *int                   `json:"classification,omitempty"`
+	LatencyCycles  *uint64                `json:"latency_cycles,omitempty"`
+}
+
+// typedOutputValue parses an output's raw simbox text value as the type
+// Config.SchemaFile declared for it (r.ColumnTypes[name]), falling back to
+// float32 when there is no schema or no entry for name. This is what lets
+// FormatJSONL emit true/false for a bool-typed output instead of forcing it
+// through strconv.ParseFloat.
+func typedOutputValue(r *Runner, name, raw string) interface{} {
+	columnType := "float32"
+	if t, ok := r.ColumnTypes[name]; ok {
+		columnType = t
+	}
+
+	switch columnType {
+	case "int32":
+		v, _ := strconv.ParseInt(raw, 10, 32)
+		return int32(v)
+	case "uint32":
+		v, _ := strconv.ParseUint(raw, 10, 32)
+		return uint32(v)
+	case "bool":
+		v, _ := strconv.ParseBool(raw)
+		return v
+	default:
+		v, _ := strconv.ParseFloat(raw, 32)
+		return float32(v)
+	}
+}
+
+// formatJSONLLine builds the {"inputs":{...}, "outputs":{...}, ...} document
+// FormatJSONL writes per row, using the design's actual input/output name
+// maps rather than positional indices.
+func formatJSONLLine(r *Runner, inputsValues []string, outline string) (string, *mlPrediction, error) {
+	var latencyCycles *uint64
+	if r.Config.BenchCore {
+		parts := strings.Fields(outline)
+		if len(parts) > 0 {
+			last := parts[len(parts)-1]
+			outline = strings.Join(parts[:len(parts)-1], " ")
+			if v, err := strconv.ParseUint(last, 10, 64); err == nil {
+				latencyCycles = &v
+			}
+		}
+	}
+
+	fields := strings.Fields(outline)
+	floatVals := make([]float32, len(fields))
+	for i, f := range fields {
+		v, _ := strconv.ParseFloat(f, 32)
+		floatVals[i] = float32(v)
+	}
+
+	outputs := make(map[string]interface{}, len(fields))
+	for i, out := range orderedOutputs(r.Outputs) {
+		if i >= len(fields) {
+			break
+		}
+		outputs[out.name] = typedOutputValue(r, out.name, fields[i])
+	}
+
+	inputs := make(map[string]string, len(inputsValues))
+	for i, v := range inputsValues {
+		inputs[r.Inputs[strconv.Itoa(i)]] = v
+	}
+
+	row := jsonlRow{Inputs: inputs, Outputs: outputs, LatencyCycles: latencyCycles}
+
+	var ml *mlPrediction
+	if r.Config.IsML {
+		predicted := findMaxIndex(floatVals)
+		row.Classification = &predicted
+		ml = &mlPrediction{predicted: predicted, probs: floatVals}
+	}
+
+	data, err := json.Marshal(row)
+	if err != nil {
+		return "", nil, fmt.Errorf("marshaling jsonl row: %w", err)
+	}
+	return string(data), ml, nil
+}
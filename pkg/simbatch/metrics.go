@@ -0,0 +1,259 @@
+package simbatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// jsonFloat is a float64 that marshals NaN/Inf as the string "NaN"/"+Inf"/
+// "-Inf" instead of making encoding/json fail outright, since metrics like
+// AUC or an empty benchmark sample set are legitimately undefined.
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	switch {
+	case math.IsNaN(v):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(v, 1):
+		return []byte(`"+Inf"`), nil
+	case math.IsInf(v, -1):
+		return []byte(`"-Inf"`), nil
+	default:
+		return json.Marshal(v)
+	}
+}
+
+// Stats summarizes a series of samples collected across benchmark runs of a
+// single row.
+type Stats struct {
+	Min    jsonFloat `json:"min"`
+	Max    jsonFloat `json:"max"`
+	Mean   jsonFloat `json:"mean"`
+	Stddev jsonFloat `json:"stddev"`
+	Median jsonFloat `json:"median"`
+	P95    jsonFloat `json:"p95"`
+}
+
+// computeStats returns Stats for values, or a Stats of NaNs if values is
+// empty (e.g. latency_cycles when BenchCore is off).
+func computeStats(values []float64) Stats {
+	if len(values) == 0 {
+		nan := jsonFloat(math.NaN())
+		return Stats{Min: nan, Max: nan, Mean: nan, Stddev: nan, Median: nan, P95: nan}
+	}
+
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	mean := sum / float64(len(sorted))
+
+	variance := 0.0
+	if len(sorted) > 1 {
+		for _, v := range sorted {
+			variance += (v - mean) * (v - mean)
+		}
+		variance /= float64(len(sorted) - 1)
+	}
+
+	n := len(sorted)
+	var median float64
+	if n%2 == 0 {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	} else {
+		median = sorted[n/2]
+	}
+
+	p95Idx := int(math.Ceil(0.95*float64(n))) - 1
+	if p95Idx < 0 {
+		p95Idx = 0
+	}
+	if p95Idx >= n {
+		p95Idx = n - 1
+	}
+
+	return Stats{
+		Min:    jsonFloat(sorted[0]),
+		Max:    jsonFloat(sorted[n-1]),
+		Mean:   jsonFloat(mean),
+		Stddev: jsonFloat(math.Sqrt(variance)),
+		Median: jsonFloat(median),
+		P95:    jsonFloat(sorted[p95Idx]),
+	}
+}
+
+// RowReport is the benchmark result for one input row, as written by
+// Config.JSONReportFile.
+type RowReport struct {
+	Inputs        []string `json:"inputs"`
+	Outputs       []string `json:"outputs"`
+	Runs          int      `json:"runs"`
+	Timeouts      int      `json:"timeouts,omitempty"`
+	LatencyCycles Stats    `json:"latency_cycles"`
+	WallNs        Stats    `json:"wall_ns"`
+}
+
+// BenchReport is the top-level document written to Config.JSONReportFile.
+type BenchReport struct {
+	Rows []RowReport `json:"rows"`
+}
+
+// ClassMetrics is the precision/recall/F1 breakdown for one class of a
+// ground-truth evaluation.
+type ClassMetrics struct {
+	Class     int       `json:"class"`
+	Precision jsonFloat `json:"precision"`
+	Recall    jsonFloat `json:"recall"`
+	F1        jsonFloat `json:"f1"`
+}
+
+// MLMetrics is the full ground-truth evaluation report, written as JSON by
+// Config.MetricsFile and summarized on stdout.
+type MLMetrics struct {
+	Accuracy  jsonFloat      `json:"accuracy"`
+	Confusion [][]int        `json:"confusion_matrix"`
+	PerClass  []ClassMetrics `json:"per_class"`
+	AUC       *jsonFloat     `json:"auc,omitempty"`
+}
+
+// computeMLMetrics scores classified rows against their ground-truth labels:
+// overall accuracy, a K x K confusion matrix (rows=actual, cols=predicted),
+// per-class precision/recall/F1, and, for the binary case, ROC AUC via the
+// tied-rank Mann-Whitney formula.
+func computeMLMetrics(results []mlEvalResult, k int) MLMetrics {
+	confusion := make([][]int, k)
+	for i := range confusion {
+		confusion[i] = make([]int, k)
+	}
+
+	correct := 0
+	for _, r := range results {
+		if r.groundTruth >= 0 && r.groundTruth < k && r.predicted >= 0 && r.predicted < k {
+			confusion[r.groundTruth][r.predicted]++
+			if r.groundTruth == r.predicted {
+				correct++
+			}
+		}
+	}
+
+	accuracy := jsonFloat(math.NaN())
+	if len(results) > 0 {
+		accuracy = jsonFloat(float64(correct) / float64(len(results)))
+	}
+
+	perClass := make([]ClassMetrics, k)
+	for c := 0; c < k; c++ {
+		tp := confusion[c][c]
+		fp, fn := 0, 0
+		for r := 0; r < k; r++ {
+			if r != c {
+				fp += confusion[r][c]
+				fn += confusion[c][r]
+			}
+		}
+
+		precision := math.NaN()
+		if tp+fp > 0 {
+			precision = float64(tp) / float64(tp+fp)
+		}
+		recall := math.NaN()
+		if tp+fn > 0 {
+			recall = float64(tp) / float64(tp+fn)
+		}
+		f1 := math.NaN()
+		if !math.IsNaN(precision) && !math.IsNaN(recall) && precision+recall > 0 {
+			f1 = 2 * precision * recall / (precision + recall)
+		}
+
+		perClass[c] = ClassMetrics{
+			Class:     c,
+			Precision: jsonFloat(precision),
+			Recall:    jsonFloat(recall),
+			F1:        jsonFloat(f1),
+		}
+	}
+
+	metrics := MLMetrics{Accuracy: accuracy, Confusion: confusion, PerClass: perClass}
+
+	if k == 2 {
+		auc := jsonFloat(computeAUC(results))
+		metrics.AUC = &auc
+	}
+
+	return metrics
+}
+
+// computeAUC computes the binary ROC AUC of results (class 1 = positive)
+// using the tied-rank formula: sort predicted positive-class probabilities,
+// assign average ranks to ties, then
+//
+//	AUC = (sum_of_ranks_of_positives - n_pos*(n_pos+1)/2) / (n_pos * n_neg)
+//
+// It returns NaN (with a warning on stderr) if there are no positives or no
+// negatives, since AUC is undefined in that case.
+func computeAUC(results []mlEvalResult) float64 {
+	probs := make([]float64, len(results))
+	positive := make([]bool, len(results))
+	nPos, nNeg := 0, 0
+	for i, r := range results {
+		probs[i] = r.posProb
+		positive[i] = r.groundTruth == 1
+		if positive[i] {
+			nPos++
+		} else {
+			nNeg++
+		}
+	}
+
+	if nPos == 0 || nNeg == 0 {
+		fmt.Fprintf(os.Stderr, "Warning: cannot compute AUC with n_pos=%d, n_neg=%d; reporting NaN\n", nPos, nNeg)
+		return math.NaN()
+	}
+
+	ranks := tiedRanks(probs)
+	sumRanksPos := 0.0
+	for i, r := range ranks {
+		if positive[i] {
+			sumRanksPos += r
+		}
+	}
+
+	return (sumRanksPos - float64(nPos)*(float64(nPos)+1)/2) / (float64(nPos) * float64(nNeg))
+}
+
+// tiedRanks assigns 1-based ranks to values in ascending order, giving tied
+// values their average rank.
+func tiedRanks(values []float64) []float64 {
+	type indexed struct {
+		idx int
+		val float64
+	}
+	sorted := make([]indexed, len(values))
+	for i, v := range values {
+		sorted[i] = indexed{idx: i, val: v}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].val < sorted[j].val })
+
+	ranks := make([]float64, len(values))
+	i := 0
+	for i < len(sorted) {
+		j := i
+		for j < len(sorted) && sorted[j].val == sorted[i].val {
+			j++
+		}
+		avgRank := float64(i+1+j) / 2
+		for kk := i; kk < j; kk++ {
+			ranks[sorted[kk].idx] = avgRank
+		}
+		i = j
+	}
+	return ranks
+}
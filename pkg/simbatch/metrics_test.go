@@ -0,0 +1,87 @@
+package simbatch
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComputeStatsEmpty(t *testing.T) {
+	stats := computeStats(nil)
+	for name, v := range map[string]jsonFloat{
+		"Min": stats.Min, "Max": stats.Max, "Mean": stats.Mean,
+		"Stddev": stats.Stddev, "Median": stats.Median, "P95": stats.P95,
+	} {
+		if !math.IsNaN(float64(v)) {
+			t.Errorf("%s = %v, want NaN", name, v)
+		}
+	}
+}
+
+func TestComputeStats(t *testing.T) {
+	stats := computeStats([]float64{1, 2, 3, 4, 5})
+
+	if got, want := float64(stats.Min), 1.0; got != want {
+		t.Errorf("Min = %v, want %v", got, want)
+	}
+	if got, want := float64(stats.Max), 5.0; got != want {
+		t.Errorf("Max = %v, want %v", got, want)
+	}
+	if got, want := float64(stats.Mean), 3.0; got != want {
+		t.Errorf("Mean = %v, want %v", got, want)
+	}
+	if got, want := float64(stats.Median), 3.0; got != want {
+		t.Errorf("Median = %v, want %v", got, want)
+	}
+}
+
+func TestTiedRanks(t *testing.T) {
+	ranks := tiedRanks([]float64{10, 20, 20, 30})
+	want := []float64{1, 2.5, 2.5, 4}
+	for i := range want {
+		if ranks[i] != want[i] {
+			t.Errorf("ranks[%d] = %v, want %v", i, ranks[i], want[i])
+		}
+	}
+}
+
+func TestComputeAUCPerfectSeparation(t *testing.T) {
+	results := []mlEvalResult{
+		{groundTruth: 0, posProb: 0.1},
+		{groundTruth: 0, posProb: 0.2},
+		{groundTruth: 1, posProb: 0.8},
+		{groundTruth: 1, posProb: 0.9},
+	}
+	if auc := computeAUC(results); auc != 1.0 {
+		t.Errorf("computeAUC = %v, want 1.0", auc)
+	}
+}
+
+func TestComputeAUCNoPositives(t *testing.T) {
+	results := []mlEvalResult{
+		{groundTruth: 0, posProb: 0.1},
+		{groundTruth: 0, posProb: 0.2},
+	}
+	if auc := computeAUC(results); !math.IsNaN(auc) {
+		t.Errorf("computeAUC = %v, want NaN", auc)
+	}
+}
+
+func TestComputeMLMetrics(t *testing.T) {
+	results := []mlEvalResult{
+		{predicted: 0, groundTruth: 0, posProb: 0.1},
+		{predicted: 1, groundTruth: 1, posProb: 0.9},
+		{predicted: 0, groundTruth: 1, posProb: 0.4},
+	}
+
+	metrics := computeMLMetrics(results, 2)
+
+	if got, want := float64(metrics.Accuracy), 2.0/3.0; got != want {
+		t.Errorf("Accuracy = %v, want %v", got, want)
+	}
+	if metrics.Confusion[1][0] != 1 || metrics.Confusion[1][1] != 1 || metrics.Confusion[0][0] != 1 {
+		t.Errorf("Confusion = %v, want [[1 0] [1 1]]", metrics.Confusion)
+	}
+	if metrics.AUC == nil {
+		t.Fatal("AUC = nil, want a value for the binary case")
+	}
+}
@@ -0,0 +1,526 @@
+// Package simbatch runs batches of BondMachine simulations against a CSV (or
+// programmatic) set of input rows. It is the library behind the simbatch CLI
+// (cmd/simbatch), factored out so SimBatch can be embedded in Go test suites
+// and higher-level pipelines without shelling out to the simbatch binary.
+package simbatch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds everything needed to bootstrap and run a batch of simulations
+// against a single compiled BondMachine design.
+type Config struct {
+	WorkingDir      string
+	SimulationSteps string
+	IsML            bool
+	BenchCore       bool
+	StopOnValidOf   int
+	LinearDataRange string
+	DataType        string
+	Header          bool
+	OmitPrefix      bool
+	DelaysFile      string
+	DelayString     string
+	Jobs            int
+	AbortOnError    bool
+	BenchRuns       int
+	JSONReportFile  string
+	Timeout         time.Duration
+	// GroundTruthCol is the 0-based input column holding each row's expected
+	// class, enabling ground-truth evaluation (accuracy/precision/recall/F1/
+	// AUC) once the batch finishes. nil disables it; a *Config{} zero value
+	// leaves it nil rather than defaulting to column 0, since embedders build
+	// Config directly and "column 0 is the label" must be an explicit choice.
+	GroundTruthCol *int
+	MetricsFile    string
+	// FastSimbox opts into assembling each row's simbox file in-process
+	// (see simbox.go) instead of the default shell-driven path that forks
+	// the simbox binary once per directive. It is off by default: the
+	// in-process encoding of the simbox-file schema is a guess reverse
+	// engineered from the shell-driven path with no captured real-simbox
+	// output to verify it against, so until that's proven, every row goes
+	// through the slower but known-correct path unless a caller opts in.
+	FastSimbox bool
+	Format     string
+	SchemaFile string
+}
+
+// RowResult is the outcome of simulating a single row via RunRow: the raw
+// simulator output plus its parsed numeric values.
+type RowResult struct {
+	Raw           string
+	Values        []float32
+	LatencyCycles *uint64
+	Duration      time.Duration
+}
+
+// Runner is a bootstrapped BondMachine design ready to simulate rows against.
+// Create one with New.
+type Runner struct {
+	Config
+	Inputs, Outputs map[string]string
+	Prefix          string
+	// OutputTypes maps an output's name to the simbox datatype
+	// (float32/int32/unsigned/bool) its onexit:show directive should use. It
+	// is nil unless Config.SchemaFile was set, in which case it overrides
+	// the Config.DataType/BenchCore-last-output convention per output.
+	OutputTypes map[string]string
+	// ColumnTypes maps an output's name to its declared schema type
+	// (float32/int32/uint32/bool), as written in Config.SchemaFile. Unlike
+	// OutputTypes, it is not translated to simbox's directive vocabulary
+	// (uint32 stays "uint32", not "unsigned"); FormatJSONL uses it to parse
+	// each output's value as its declared type. It is nil unless
+	// Config.SchemaFile was set.
+	ColumnTypes map[string]string
+	simboxFile  string
+}
+
+// New bootstraps a Runner for the design in cfg.WorkingDir: it loads the
+// design's input/output port names and the number prefix for cfg.DataType by
+// shelling out to `bondmachine`/`bmnumbers`, the same way the simbatch CLI
+// always has, plus the optional per-output type schema (Config.SchemaFile).
+func New(cfg Config) (*Runner, error) {
+	inputs, err := loadInputsOrOutputs(cfg.WorkingDir, "-list-inputs", cfg.LinearDataRange)
+	if err != nil {
+		return nil, fmt.Errorf("loading inputs: %w", err)
+	}
+
+	outputs, err := loadInputsOrOutputs(cfg.WorkingDir, "-list-outputs", cfg.LinearDataRange)
+	if err != nil {
+		return nil, fmt.Errorf("loading outputs: %w", err)
+	}
+
+	prefix, err := getPrefix(cfg.DataType, cfg.LinearDataRange)
+	if err != nil {
+		return nil, fmt.Errorf("getting prefix: %w", err)
+	}
+
+	var outputTypes, columnTypes map[string]string
+	if cfg.SchemaFile != "" {
+		schema, err := loadSchema(cfg.SchemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading schema: %w", err)
+		}
+		outputTypes, err = schema.outputTypes()
+		if err != nil {
+			return nil, fmt.Errorf("loading schema: %w", err)
+		}
+		columnTypes = schema.columnTypes()
+	}
+
+	return &Runner{
+		Config:      cfg,
+		Inputs:      inputs,
+		Outputs:     outputs,
+		Prefix:      prefix,
+		OutputTypes: outputTypes,
+		ColumnTypes: columnTypes,
+		simboxFile:  cfg.WorkingDir + "/simboxtemp.json",
+	}, nil
+}
+
+// WithSimboxFile returns a shallow copy of r that drives simulations through
+// a different simbox file, so concurrent callers (RunCSV's worker pool, or a
+// caller sharding RunRow calls across goroutines itself) never race on the
+// same file.
+func (r *Runner) WithSimboxFile(path string) *Runner {
+	clone := *r
+	clone.simboxFile = path
+	return &clone
+}
+
+// RunRow simulates a single row of input values and returns its raw output
+// together with the parsed per-output values, the benchCore latency_cycles
+// (if enabled), and how long the simulation took.
+func (r *Runner) RunRow(ctx context.Context, values []string) (RowResult, error) {
+	if len(values) != len(r.Inputs) {
+		return RowResult{}, fmt.Errorf("expected %d input values, got %d", len(r.Inputs), len(values))
+	}
+
+	if err := prepareSimbox(r, values); err != nil {
+		return RowResult{}, err
+	}
+
+	start := time.Now()
+	outline, err := runSimOnce(ctx, r)
+	duration := time.Since(start)
+	if err != nil {
+		return RowResult{}, err
+	}
+
+	parsedValues, latency := parseOutline(r, outline)
+	return RowResult{Raw: outline, Values: parsedValues, LatencyCycles: latency, Duration: duration}, nil
+}
+
+// RunCSV reads rows from in (one comma-separated row per line), simulates
+// each one, and writes the results to out as CSV, TSV or JSONL (per
+// Config.Format). It honors every batch-level
+// Config option: parallel workers (Jobs/AbortOnError), benchmark mode
+// (BenchRuns/Timeout/JSONReportFile), and ground-truth evaluation
+// (GroundTruthCol/MetricsFile).
+func (r *Runner) RunCSV(ctx context.Context, in io.Reader, out io.Writer) error {
+	writer := bufio.NewWriter(out)
+	defer writer.Flush()
+
+	if r.Config.Header && r.Config.Format != FormatJSONL {
+		sep := delimiterFor(r.Config.Format)
+		if r.Config.IsML {
+			for i := 0; i < len(r.Outputs); i++ {
+				fmt.Fprintf(writer, "probability_%d%s", i, sep)
+			}
+			fmt.Fprint(writer, "classification")
+		}
+		if r.Config.BenchCore {
+			fmt.Fprintf(writer, "%slatency_cycles", sep)
+		}
+		fmt.Fprintln(writer)
+	}
+
+	var rows []inputRow
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Split(line, ",")
+		groundTruth := 0
+		if col := r.Config.GroundTruthCol; col != nil {
+			if *col >= len(fields) {
+				fmt.Fprintf(os.Stderr, "Error: ground-truth column %d out of range\n", *col)
+				continue
+			}
+			t, err := strconv.Atoi(strings.TrimSpace(fields[*col]))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid ground-truth value %q: %v\n", fields[*col], err)
+				continue
+			}
+			groundTruth = t
+			fields = append(fields[:*col:*col], fields[*col+1:]...)
+		}
+
+		if len(fields) != len(r.Inputs) {
+			fmt.Fprintf(os.Stderr, "Error: The input file has an invalid number of columns\n")
+			continue
+		}
+
+		rows = append(rows, inputRow{line: strings.Join(fields, ","), groundTruth: groundTruth})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading input: %w", err)
+	}
+
+	reports, mlResults, err := runRowsParallel(ctx, r, rows, writer)
+	if err != nil {
+		return err
+	}
+
+	if r.Config.JSONReportFile != "" && reports != nil {
+		if err := writeJSONReport(r.Config.JSONReportFile, BenchReport{Rows: reports}); err != nil {
+			return err
+		}
+	}
+
+	if r.Config.IsML && r.Config.GroundTruthCol != nil && len(mlResults) > 0 {
+		metrics := computeMLMetrics(mlResults, len(r.Outputs))
+		fmt.Printf("Accuracy: %.4f\n", float64(metrics.Accuracy))
+		for _, cm := range metrics.PerClass {
+			fmt.Printf("Class %d: precision=%.4f recall=%.4f f1=%.4f\n", cm.Class, float64(cm.Precision), float64(cm.Recall), float64(cm.F1))
+		}
+		if metrics.AUC != nil {
+			fmt.Printf("AUC: %.4f\n", float64(*metrics.AUC))
+		}
+
+		if r.Config.MetricsFile != "" {
+			if err := writeJSONReport(r.Config.MetricsFile, metrics); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeJSONReport(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+func runCommand(command string) (string, error) {
+	return runCommandCtx(context.Background(), command)
+}
+
+// runCommandCtx is runCommand with a context, used by the Timeout option to
+// kill a stuck simulation via exec.CommandContext instead of hanging the
+// batch forever.
+func runCommandCtx(ctx context.Context, command string) (string, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", context.DeadlineExceeded
+		}
+		return "", fmt.Errorf("command failed: %v, output: %s", err, string(output))
+	}
+	return string(output), nil
+}
+
+func loadInputsOrOutputs(workingDir, option, linearDataRange string) (map[string]string, error) {
+	command := fmt.Sprintf("bondmachine -bondmachine-file %s/bondmachine.json %s %s", workingDir, option, linearDataRange)
+	output, err := runCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.Fields(line)
+		if len(parts) == 2 {
+			result[parts[0]] = parts[1]
+		}
+	}
+	return result, scanner.Err()
+}
+
+func getPrefix(dataType, linearDataRange string) (string, error) {
+	command := fmt.Sprintf("bmnumbers -get-prefix %s %s", dataType, linearDataRange)
+	output, err := runCommand(command)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
+func findMaxIndex(values []float32) int {
+	if len(values) == 0 {
+		return -1
+	}
+	maxIdx := 0
+	maxVal := values[0]
+	for i, v := range values {
+		if v > maxVal {
+			maxVal = v
+			maxIdx = i
+		}
+	}
+	return maxIdx
+}
+
+// prepareSimbox resets r's simbox file and writes the show/suspend/input/
+// output directives a row needs before it can be simulated. It is shared by
+// the regular single-shot path and the benchmark path, which reruns the
+// simulation against the same prepared simbox file.
+//
+// By default this drives the simbox binary one sub-process per directive,
+// the same way this package always has. Config.FastSimbox opts into
+// assembling the directives in-process and writing them to disk in a single
+// os.WriteFile call instead (see simbox.go), avoiding the ~10+len(inputs)+
+// len(outputs) `simbox` sub-process forks the shell-driven path needs per
+// row — but that in-process encoding isn't verified against real simbox
+// output, so it stays opt-in.
+func prepareSimbox(r *Runner, inputsValues []string) error {
+	if !r.Config.FastSimbox {
+		return prepareSimboxLegacy(r, inputsValues)
+	}
+
+	doc := buildSimboxDoc(r, inputsValues)
+	return writeSimboxFile(r.simboxFile, doc)
+}
+
+// prepareSimboxLegacy is the original implementation of prepareSimbox: it
+// drives the simbox binary one sub-process per directive. It is the default
+// path unless Config.FastSimbox opts into the in-process builder.
+func prepareSimboxLegacy(r *Runner, inputsValues []string) error {
+	simboxFile := r.simboxFile
+
+	// Remove simbox file
+	os.Remove(simboxFile)
+
+	commands := []string{
+		fmt.Sprintf("simbox -simbox-file %s -add \"config:show_io_pre\"", simboxFile),
+		fmt.Sprintf("simbox -simbox-file %s -suspend 0", simboxFile),
+		fmt.Sprintf("simbox -simbox-file %s -add \"config:show_io_post\"", simboxFile),
+		fmt.Sprintf("simbox -simbox-file %s -suspend 1", simboxFile),
+		fmt.Sprintf("simbox -simbox-file %s -add \"config:show_ticks\"", simboxFile),
+		fmt.Sprintf("simbox -simbox-file %s -suspend 2", simboxFile),
+		fmt.Sprintf("simbox -simbox-file %s -add \"config:show_pc\"", simboxFile),
+		fmt.Sprintf("simbox -simbox-file %s -suspend 3", simboxFile),
+		fmt.Sprintf("simbox -simbox-file %s -add \"config:show_disasm\"", simboxFile),
+		fmt.Sprintf("simbox -simbox-file %s -suspend 4", simboxFile),
+	}
+
+	for _, cmd := range commands {
+		if _, err := runCommand(cmd); err != nil {
+			return fmt.Errorf("preparing simbox command: %s\n%v", cmd, err)
+		}
+	}
+
+	for i := 0; i < len(inputsValues); i++ {
+		inputName := r.Inputs[strconv.Itoa(i)]
+		inputValue := inputsValues[i]
+		cmd := fmt.Sprintf("simbox -simbox-file %s -add \"absolute:0:set:%s:%s\"", simboxFile, inputName, inputValue)
+		if _, err := runCommand(cmd); err != nil {
+			return fmt.Errorf("setting input %s to %s\n%v", inputName, inputValue, err)
+		}
+	}
+
+	for outputName, outputVal := range r.Outputs {
+		cmd := fmt.Sprintf("simbox -simbox-file %s -add \"onexit:show:%s:%s\"", simboxFile, outputVal, outputSimboxType(r, outputName, outputVal))
+		if _, err := runCommand(cmd); err != nil {
+			return fmt.Errorf("getting output %s\n%v", outputName, err)
+		}
+	}
+
+	return nil
+}
+
+// runSimOnce executes the bondmachine -sim invocation against an already
+// prepared simbox file and returns its raw output, trimmed and with the
+// number prefix stripped if requested. ctx is honored via exec.CommandContext
+// so a Timeout can kill a stuck simulation.
+func runSimOnce(ctx context.Context, r *Runner) (string, error) {
+	stopOnValidOf := len(r.Outputs) - 1
+
+	simCmd := fmt.Sprintf("bondmachine -bondmachine-file %s/bondmachine.json %s -simbox-file %s -sim-stop-on-valid-of %d -sim -sim-interactions %s %s",
+		r.Config.WorkingDir, r.Config.DelayString, r.simboxFile, stopOnValidOf, r.Config.SimulationSteps, r.Config.LinearDataRange)
+	simOutput, err := runCommandCtx(ctx, simCmd)
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			return "", err
+		}
+		return "", fmt.Errorf("running simulation\n%v", err)
+	}
+
+	outline := strings.TrimSpace(simOutput)
+	if r.Config.OmitPrefix {
+		outline = strings.ReplaceAll(outline, r.Prefix, "")
+	}
+	return outline, nil
+}
+
+// parseOutline splits a raw simulation outline into its per-output float32
+// values and, in benchCore mode, the trailing latency_cycles counter.
+func parseOutline(r *Runner, outline string) (values []float32, latencyCycles *uint64) {
+	fields := strings.Fields(outline)
+	if r.Config.BenchCore && len(fields) > 0 {
+		last := fields[len(fields)-1]
+		fields = fields[:len(fields)-1]
+		if v, err := strconv.ParseUint(last, 10, 64); err == nil {
+			latencyCycles = &v
+		}
+	}
+
+	values = make([]float32, len(fields))
+	for i, f := range fields {
+		v, _ := strconv.ParseFloat(f, 32)
+		values[i] = float32(v)
+	}
+	return values, latencyCycles
+}
+
+// formatCSVLine turns a raw, prefix-stripped simulation outline into the
+// delimited (CSV or TSV, per cfg.Format) line RunCSV writes to its output,
+// splitting off the benchCore latency_cycles column (if any) along the way.
+func formatCSVLine(cfg Config, outline string) (csvLine string, latencyCycles string, probs []float32, predicted int) {
+	predicted = -1
+	sep := delimiterFor(cfg.Format)
+
+	if cfg.BenchCore {
+		parts := strings.Fields(outline)
+		if len(parts) > 0 {
+			latencyCycles = parts[len(parts)-1]
+			outline = strings.Join(parts[:len(parts)-1], " ")
+		}
+	}
+
+	if cfg.IsML {
+		vals := strings.Fields(outline)
+		floatVals := make([]float32, len(vals))
+		for i, v := range vals {
+			f, _ := strconv.ParseFloat(v, 32)
+			floatVals[i] = float32(f)
+		}
+		probs = floatVals
+		predicted = findMaxIndex(floatVals)
+		outline = strings.ReplaceAll(outline, " ", sep)
+		outline = fmt.Sprintf("%s%s%d", outline, sep, predicted)
+	} else {
+		outline = strings.Trim(outline, ",")
+		outline = strings.ReplaceAll(outline, " ", sep)
+	}
+
+	if cfg.BenchCore {
+		outline = fmt.Sprintf("%s%s%s", outline, sep, latencyCycles)
+	}
+
+	return outline, latencyCycles, probs, predicted
+}
+
+// delimiterFor returns the column separator for a Config.Format value.
+func delimiterFor(format string) string {
+	if format == FormatTSV {
+		return "\t"
+	}
+	return ","
+}
+
+// mlPrediction is the classification outcome of one ML-mode row, carried
+// alongside its CSV line so GroundTruthCol evaluation can score it once the
+// whole batch has finished.
+type mlPrediction struct {
+	predicted int
+	probs     []float32
+}
+
+// processRowCSV runs a single row against its own simbox file and returns the
+// fully formatted output line, ready to be written to the output CSV as-is.
+// Config.Timeout (if set) applies here the same way it does in benchRowCSV,
+// so a stuck simulation doesn't hang the batch just because -B wasn't used.
+func processRowCSV(ctx context.Context, r *Runner, line string) (string, *mlPrediction, error) {
+	inputsValues := strings.Split(line, ",")
+	if len(inputsValues) != len(r.Inputs) {
+		return "", nil, fmt.Errorf("the input file has an invalid number of columns")
+	}
+
+	fmt.Printf("Running simulation with inputs: %s\n", line)
+
+	if err := prepareSimbox(r, inputsValues); err != nil {
+		return "", nil, err
+	}
+
+	runCtx := ctx
+	if r.Config.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, r.Config.Timeout)
+		defer cancel()
+	}
+
+	outline, err := runSimOnce(runCtx, r)
+	if err != nil {
+		if err == context.DeadlineExceeded {
+			return "", nil, fmt.Errorf("simulation timed out after %s", r.Config.Timeout)
+		}
+		return "", nil, err
+	}
+
+	return formatRow(r, inputsValues, outline)
+}
@@ -0,0 +1,42 @@
+package simbatch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestRunSimOnceDeadlineExceeded is a regression test for a bug where
+// runSimOnce rewrapped every runCommandCtx error, including the
+// context.DeadlineExceeded sentinel, with fmt.Errorf. That made the
+// "err == context.DeadlineExceeded" check in benchRowCSV dead code: a single
+// slow run aborted the whole row instead of being counted as a timeout.
+func TestRunSimOnceDeadlineExceeded(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test fakes bondmachine with a sh script")
+	}
+
+	binDir := t.TempDir()
+	fakeBondmachine := filepath.Join(binDir, "bondmachine")
+	if err := os.WriteFile(fakeBondmachine, []byte("#!/bin/sh\nsleep 1\n"), 0755); err != nil {
+		t.Fatalf("writing fake bondmachine: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	r := &Runner{
+		Config:  Config{WorkingDir: t.TempDir()},
+		Outputs: map[string]string{"out0": "o0"},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := runSimOnce(ctx, r)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("runSimOnce error = %v, want context.DeadlineExceeded", err)
+	}
+}
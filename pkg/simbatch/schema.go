@@ -0,0 +1,100 @@
+package simbatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// OutputColumn describes one output port's data type, as listed in a
+// Config.SchemaFile document.
+type OutputColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Schema is the document Config.SchemaFile holds: the per-output data types
+// used to build each output's onexit:show:oX:<type> simbox directive,
+// replacing the hard-coded "last output is benchCore's unsigned latency
+// counter" convention for designs with heterogeneous output ports.
+type Schema struct {
+	Columns []OutputColumn `json:"columns"`
+}
+
+// loadSchema reads and validates a Config.SchemaFile document.
+func loadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for _, col := range schema.Columns {
+		if _, err := simboxType(col.Type); err != nil {
+			return nil, fmt.Errorf("column %q: %w", col.Name, err)
+		}
+	}
+
+	return &schema, nil
+}
+
+// outputTypes converts a Schema into the name -> simbox-datatype map Runner
+// uses to build onexit:show directives.
+func (s *Schema) outputTypes() (map[string]string, error) {
+	types := make(map[string]string, len(s.Columns))
+	for _, col := range s.Columns {
+		t, err := simboxType(col.Type)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", col.Name, err)
+		}
+		types[col.Name] = t
+	}
+	return types, nil
+}
+
+// columnTypes returns the name -> declared column type map (float32/int32/
+// uint32/bool, as written in the schema file), used by FormatJSONL to parse
+// each output's value as its declared type instead of always as a float.
+func (s *Schema) columnTypes() map[string]string {
+	types := make(map[string]string, len(s.Columns))
+	for _, col := range s.Columns {
+		types[col.Name] = col.Type
+	}
+	return types
+}
+
+// simboxType maps a schema column type to the literal type name the
+// onexit:show:oX:<type> simbox directive expects.
+func simboxType(columnType string) (string, error) {
+	switch columnType {
+	case "float32", "int32", "bool":
+		return columnType, nil
+	case "uint32":
+		return "unsigned", nil
+	default:
+		return "", fmt.Errorf("unsupported type %q (want float32, int32, uint32, or bool)", columnType)
+	}
+}
+
+// outputSimboxType returns the simbox datatype to use for outputName/
+// outputPort's onexit:show directive: the schema's type if Config.SchemaFile
+// set one, otherwise the Config.DataType/BenchCore-last-output fallback
+// prepareSimboxLegacy has always used.
+func outputSimboxType(r *Runner, outputName, outputPort string) string {
+	if r.OutputTypes != nil {
+		if t, ok := r.OutputTypes[outputName]; ok {
+			return t
+		}
+	}
+
+	lastOutput := "o" + strconv.Itoa(len(r.Outputs)-1)
+	if r.Config.BenchCore && outputPort == lastOutput {
+		return "unsigned"
+	}
+	return r.Config.DataType
+}
@@ -0,0 +1,104 @@
+package simbatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// simboxOp is one entry of a simboxDoc: either a directive to add ("add") or
+// a suspend point ("suspend"), mirroring the two `simbox` sub-commands
+// (-add/-suspend) prepareSimboxLegacy drives one fork/exec at a time. A
+// simboxDoc is written as JSON in one shot; whether this encoding matches
+// the file simbox itself accumulates on disk is checked only by
+// TestPrepareSimboxSchemaParity, which needs the simbox binary.
+type simboxOp struct {
+	Op        string `json:"op"`
+	Directive string `json:"directive,omitempty"`
+	Tick      int    `json:"tick,omitempty"`
+}
+
+// simboxDoc is the full simbox-file document for one row: the ordered
+// sequence of add/suspend operations prepareSimboxLegacy would otherwise
+// perform through repeated `simbox` invocations.
+type simboxDoc struct {
+	Ops []simboxOp `json:"ops"`
+}
+
+// buildSimboxDoc assembles the same directive/suspend sequence
+// prepareSimboxLegacy sends to `simbox`, in-process and in one pass.
+func buildSimboxDoc(r *Runner, inputsValues []string) simboxDoc {
+	doc := simboxDoc{}
+
+	add := func(directive string) {
+		doc.Ops = append(doc.Ops, simboxOp{Op: "add", Directive: directive})
+	}
+	suspend := func(tick int) {
+		doc.Ops = append(doc.Ops, simboxOp{Op: "suspend", Tick: tick})
+	}
+
+	add("config:show_io_pre")
+	suspend(0)
+	add("config:show_io_post")
+	suspend(1)
+	add("config:show_ticks")
+	suspend(2)
+	add("config:show_pc")
+	suspend(3)
+	add("config:show_disasm")
+	suspend(4)
+
+	for i := 0; i < len(inputsValues); i++ {
+		inputName := r.Inputs[strconv.Itoa(i)]
+		inputValue := inputsValues[i]
+		add(fmt.Sprintf("absolute:0:set:%s:%s", inputName, inputValue))
+	}
+
+	for _, out := range orderedOutputs(r.Outputs) {
+		add(fmt.Sprintf("onexit:show:%s:%s", out.port, outputSimboxType(r, out.name, out.port)))
+	}
+
+	return doc
+}
+
+// namedOutput is one output port paired with its semantic name.
+type namedOutput struct {
+	name string
+	port string
+}
+
+// orderedOutputs returns outputs' name/port pairs in ascending port-index
+// ("oN") order, so buildSimboxDoc produces a deterministic ops sequence
+// regardless of Go's randomized map iteration order.
+func orderedOutputs(outputs map[string]string) []namedOutput {
+	ordered := make([]namedOutput, 0, len(outputs))
+	for name, port := range outputs {
+		ordered = append(ordered, namedOutput{name: name, port: port})
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return outputPortIndex(ordered[i].port) < outputPortIndex(ordered[j].port)
+	})
+	return ordered
+}
+
+func outputPortIndex(port string) int {
+	n, _ := strconv.Atoi(port[1:])
+	return n
+}
+
+// writeSimboxFile resets path and writes doc to it as JSON in a single
+// os.WriteFile call.
+func writeSimboxFile(path string, doc simboxDoc) error {
+	os.Remove(path)
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("marshaling simbox file %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing simbox file %s: %w", path, err)
+	}
+	return nil
+}